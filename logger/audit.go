@@ -0,0 +1,235 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lestrrat/go-libxml2"
+	"github.com/lestrrat/go-libxml2/xsd"
+)
+
+// defaultBufferSize bounds how many entries can be buffered between the
+// request path and the background writer before Enqueue starts dropping.
+const defaultBufferSize = 256
+
+// AuditLogger writes LogType entries to a Sink from a single background
+// goroutine, so request handlers never block on disk I/O or XSD validation.
+// Entries are not batched: each one is a separate Sink.Write call.
+type AuditLogger struct {
+	sink   Sink
+	debug  bool
+	schema *xsd.Schema
+
+	events chan LogType
+	flush  chan chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	queued  uint64
+	dropped uint64
+}
+
+// newAuditLogger parses schema.xsd once (when debug is set) and starts the
+// background writer goroutine.
+func newAuditLogger(sink Sink, debug bool) (*AuditLogger, error) {
+	l := &AuditLogger{
+		sink:   sink,
+		debug:  debug,
+		events: make(chan LogType, defaultBufferSize),
+		flush:  make(chan chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if debug {
+		schema, err := loadSchema()
+		if err != nil {
+			return nil, err
+		}
+		l.schema = schema
+	}
+
+	l.wg.Add(1)
+	go l.run()
+
+	return l, nil
+}
+
+func loadSchema() (*xsd.Schema, error) {
+	buf, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", schemaFile, err)
+	}
+
+	schema, err := xsd.Parse(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse XSD: %w", err)
+	}
+
+	return schema, nil
+}
+
+// Enqueue hands entry to the background writer. It never blocks: if the
+// buffer is full, or Shutdown has been called, the entry is dropped and
+// counted so callers can alert on back-pressure instead of stalling the
+// request path.
+func (l *AuditLogger) Enqueue(entry LogType) {
+	select {
+	case <-l.done:
+		atomic.AddUint64(&l.dropped, 1)
+		return
+	default:
+	}
+
+	select {
+	case l.events <- entry:
+		atomic.AddUint64(&l.queued, 1)
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+}
+
+// Flush blocks until every entry Enqueued before this call has been written
+// to the sink, or ctx expires. Callers that read the sink right after
+// Enqueueing (e.g. DUMPLOG reading back the file it just logged) need this
+// to avoid racing the background writer.
+func (l *AuditLogger) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+
+	select {
+	case l.flush <- ack:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metrics reports how many entries have been accepted and dropped since
+// startup.
+func (l *AuditLogger) Metrics() (queued, dropped uint64) {
+	return atomic.LoadUint64(&l.queued), atomic.LoadUint64(&l.dropped)
+}
+
+// Shutdown stops accepting new entries, flushes whatever is already
+// buffered, and waits for the writer goroutine to exit or ctx to expire.
+func (l *AuditLogger) Shutdown(ctx context.Context) error {
+	close(l.done)
+
+	flushed := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return l.sink.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *AuditLogger) run() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case entry := <-l.events:
+			l.write(entry)
+		case ack := <-l.flush:
+			l.drain()
+			close(ack)
+		case <-l.done:
+			l.drain()
+			return
+		}
+	}
+}
+
+// drain writes whatever is currently buffered in events without blocking,
+// which is all Flush and Shutdown can promise: entries enqueued after the
+// call started aren't guaranteed to be included.
+func (l *AuditLogger) drain() {
+	for {
+		select {
+		case entry := <-l.events:
+			l.write(entry)
+		default:
+			return
+		}
+	}
+}
+
+// write validates entry (in debug mode only) and appends just its inner
+// element to the sink, so the on-disk log is a flat stream of
+// <userCommand>/<accountTransaction>/<systemEvent>/<quoteServer>/<errorEventm>
+// fragments rather than one <log> root per entry. DumpLog/DumpLogAll are
+// what wrap a run of those fragments in a single <log> root.
+func (l *AuditLogger) write(entry LogType) {
+	if l.debug {
+		full, err := xml.MarshalIndent(entry, prefix, indent)
+		if err != nil {
+			fmt.Printf("failed to marshal log entry: %s", err)
+			return
+		}
+		validateSchema(l.schema, full)
+	}
+
+	name, v := entry.element()
+	if name == "" {
+		return
+	}
+
+	output, err := marshalElement(name, v)
+	if err != nil {
+		fmt.Printf("failed to marshal log entry: %s", err)
+		return
+	}
+
+	if err := l.sink.Write(output); err != nil {
+		fmt.Printf("failed to write log entry: %s", err)
+	}
+}
+
+// marshalElement marshals v as an element named name, regardless of v's own
+// type name, so fragments on disk use the same tags LogType uses for
+// omitempty marshaling.
+func marshalElement(name string, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	enc.Indent(prefix, indent)
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeElement(v, start); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func validateSchema(schema *xsd.Schema, ele []byte) {
+	d, err := libxml2.Parse(ele)
+	if err != nil {
+		fmt.Printf("failed to parse XML: %s", err)
+		return
+	}
+
+	if err := schema.Validate(d); err != nil {
+		for _, e := range err.(xsd.SchemaValidationError).Errors() {
+			fmt.Printf("error: %s", e.Error())
+		}
+	}
+}
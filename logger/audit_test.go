@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memSink is a Sink that records each Write call in memory, for asserting
+// what AuditLogger's background writer actually produced. If block is set,
+// Write parks on it after recording, so a test can hold the writer goroutine
+// busy on one entry while it fills the events channel behind it.
+type memSink struct {
+	mu     sync.Mutex
+	writes [][]byte
+	closed bool
+	block  chan struct{}
+}
+
+func (s *memSink) Write(p []byte) error {
+	s.mu.Lock()
+	s.writes = append(s.writes, append([]byte(nil), p...))
+	block := s.block
+	s.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+	return nil
+}
+
+func (s *memSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *memSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.writes)
+}
+
+func TestAuditLoggerFlushWaitsForEnqueued(t *testing.T) {
+	sink := &memSink{}
+	l, err := newAuditLogger(sink, false)
+	if err != nil {
+		t.Fatalf("newAuditLogger: %s", err)
+	}
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		l.Enqueue(LogType{SystemEvent: &SystemEventType{Username: "alice"}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %s", err)
+	}
+
+	if got := sink.count(); got != n {
+		t.Fatalf("sink recorded %d writes after Flush, want %d", got, n)
+	}
+}
+
+func TestAuditLoggerShutdownDrainsBuffer(t *testing.T) {
+	sink := &memSink{}
+	l, err := newAuditLogger(sink, false)
+	if err != nil {
+		t.Fatalf("newAuditLogger: %s", err)
+	}
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		l.Enqueue(LogType{SystemEvent: &SystemEventType{Username: "bob"}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %s", err)
+	}
+
+	if got := sink.count(); got != n {
+		t.Fatalf("sink recorded %d writes after Shutdown, want %d", got, n)
+	}
+	if !sink.closed {
+		t.Fatal("Shutdown did not close the sink")
+	}
+}
+
+func TestAuditLoggerEnqueueDropsWhenFull(t *testing.T) {
+	block := make(chan struct{})
+	sink := &memSink{block: block}
+	l, err := newAuditLogger(sink, false)
+	if err != nil {
+		t.Fatalf("newAuditLogger: %s", err)
+	}
+
+	// The first entry is picked up immediately and parks the writer
+	// goroutine in Write, so nothing behind it in events gets drained.
+	l.Enqueue(LogType{SystemEvent: &SystemEventType{Username: "carol"}})
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < defaultBufferSize*2; i++ {
+		l.Enqueue(LogType{SystemEvent: &SystemEventType{Username: "carol"}})
+	}
+
+	queued, dropped := l.Metrics()
+	if dropped == 0 {
+		t.Fatalf("expected some entries to be dropped once the buffer filled; queued=%d dropped=%d", queued, dropped)
+	}
+
+	close(block)
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %s", err)
+	}
+}
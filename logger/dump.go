@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// dumpEntry pairs a decoded fragment with the fields DumpLog needs to filter
+// and order it: the username to match against and the timestamp to sort by.
+type dumpEntry struct {
+	timestamp int64
+	username  string
+	log       LogType
+}
+
+// DumpLogAll writes every entry in the audit log to filename as a single,
+// schema-valid <log> document.
+func DumpLogAll(filename string) error {
+	return dumpLog(filename, "")
+}
+
+// DumpLog writes username's entries from the audit log to filename as a
+// single, schema-valid <log> document.
+func DumpLog(username, filename string) error {
+	return dumpLog(filename, username)
+}
+
+func dumpLog(filename, username string) error {
+	if !fileBacked {
+		return fmt.Errorf("DUMPLOG requires InitLogger to be configured with a FileSink")
+	}
+
+	entries, err := readLogFragments(logfile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", logfile, err)
+	}
+
+	if username != "" {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.username == username {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].timestamp < entries[j].timestamp
+	})
+
+	output, err := marshalLogDocument(entries)
+	if err != nil {
+		return err
+	}
+
+	validateLogDocument(output)
+
+	return ioutil.WriteFile(filename, output, 0600)
+}
+
+// marshalLogDocument wraps entries' fragments in a single <log> root.
+func marshalLogDocument(entries []dumpEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("<log>\n")
+
+	for _, e := range entries {
+		name, v := e.log.element()
+		if name == "" {
+			continue
+		}
+		fragment, err := marshalElement(name, v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(fragment)
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteString("</log>\n")
+	return buf.Bytes(), nil
+}
+
+// validateLogDocument is a best-effort check: schema problems are printed,
+// not returned, so a bad XSD or a non-conformant fragment can't stop the
+// dump from being written.
+func validateLogDocument(doc []byte) {
+	schema, err := loadSchema()
+	if err != nil {
+		fmt.Printf("failed to load schema for dump validation: %s", err)
+		return
+	}
+	defer schema.Free()
+
+	validateSchema(schema, doc)
+}
+
+// readLogFragments decodes the flat stream of element fragments that
+// AuditLogger.write appends to logfile. Since the file holds a sequence of
+// sibling elements rather than one well-formed document, it's read with a
+// streaming xml.Decoder instead of xml.Unmarshal.
+func readLogFragments(path string) ([]dumpEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dec := xml.NewDecoder(file)
+	var entries []dumpEntry
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		entry, err := decodeFragment(dec, start)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func decodeFragment(dec *xml.Decoder, start xml.StartElement) (*dumpEntry, error) {
+	switch start.Name.Local {
+	case "userCommand":
+		var v UserCommandType
+		if err := dec.DecodeElement(&v, &start); err != nil {
+			return nil, err
+		}
+		return newDumpEntry(v.Timestamp, v.Username, LogType{UserCommand: &v})
+	case "accountTransaction":
+		var v AccountTransactionType
+		if err := dec.DecodeElement(&v, &start); err != nil {
+			return nil, err
+		}
+		return newDumpEntry(v.Timestamp, v.Username, LogType{AccountTransaction: &v})
+	case "systemEvent":
+		var v SystemEventType
+		if err := dec.DecodeElement(&v, &start); err != nil {
+			return nil, err
+		}
+		return newDumpEntry(v.Timestamp, v.Username, LogType{SystemEvent: &v})
+	case "quoteServer":
+		var v QuoteServerType
+		if err := dec.DecodeElement(&v, &start); err != nil {
+			return nil, err
+		}
+		return newDumpEntry(v.Timestamp, v.Username, LogType{QuoteServer: &v})
+	case "errorEventm":
+		var v ErrorEventType
+		if err := dec.DecodeElement(&v, &start); err != nil {
+			return nil, err
+		}
+		return newDumpEntry(v.Timestamp, v.Username, LogType{ErrorEvent: &v})
+	default:
+		return nil, nil
+	}
+}
+
+// AccountTransactionsForUser returns the AccountTransaction entries logged
+// for username with a timestamp in [from, to], ordered oldest first. Callers
+// such as OFX export want executed cash movements, not the pending state
+// tracked by the reservations table.
+func AccountTransactionsForUser(username string, from, to time.Time) ([]AccountTransactionType, error) {
+	if !fileBacked {
+		return nil, fmt.Errorf("AccountTransactionsForUser requires InitLogger to be configured with a FileSink")
+	}
+
+	entries, err := readLogFragments(logfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", logfile, err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].timestamp < entries[j].timestamp
+	})
+
+	fromMillis := from.UnixNano() / int64(time.Millisecond)
+	toMillis := to.UnixNano() / int64(time.Millisecond)
+
+	var out []AccountTransactionType
+	for _, e := range entries {
+		if e.log.AccountTransaction == nil || e.username != username {
+			continue
+		}
+		if e.timestamp < fromMillis || e.timestamp > toMillis {
+			continue
+		}
+		out = append(out, *e.log.AccountTransaction)
+	}
+
+	return out, nil
+}
+
+func newDumpEntry(timestamp, username string, log LogType) (*dumpEntry, error) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+	}
+	return &dumpEntry{timestamp: ts, username: username, log: log}, nil
+}
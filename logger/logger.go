@@ -7,17 +7,21 @@
 package logger
 
 import (
-	"encoding/xml"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
-	"github.com/lestrrat/go-libxml2"
-	"github.com/lestrrat/go-libxml2/xsd"
+	"github.com/shopspring/decimal"
+
+	"transaction_service/queries/models"
 )
 
+// flushTimeout bounds how long dump() waits for the audit log's background
+// writer to catch up before reading the file back.
+const flushTimeout = 5 * time.Second
+
 type Command string
 
 const (
@@ -74,7 +78,7 @@ type UserCommandType struct {
 	Username          string  `xml:"username,omitempty"`
 	Symbol            string  `xml:"stockSymbol,omitempty"`
 	Filename          string  `xml:"filename,omitempty"`
-	Funds             string  `xml:"funds,omitempty"`
+	Funds             *money  `xml:"funds,omitempty"`
 }
 
 type AccountTransactionType struct {
@@ -83,7 +87,7 @@ type AccountTransactionType struct {
 	TransactionNumber string `xml:"transactionNum"`
 	Command           string `xml:"action"`
 	Username          string `xml:"username"`
-	Funds             string `xml:"funds"`
+	Funds             money  `xml:"funds"`
 }
 
 type SystemEventType struct {
@@ -93,7 +97,7 @@ type SystemEventType struct {
 	Command           string `xml:"action"`
 	Username          string `xml:"username"`
 	StockSymbol       string `xml:"stockSymbol"`
-	Funds             string `xml:"funds"`
+	Funds             money  `xml:"funds"`
 }
 
 type QuoteServerType struct {
@@ -103,10 +107,48 @@ type QuoteServerType struct {
 	QuoteServerTime   string `xml:"quoteServerTime"`
 	Username          string `xml:"username"`
 	StockSymbol       string `xml:"stockSymbol"`
-	Price             string `xml:"price"`
+	Price             money  `xml:"price"`
 	CryptoKey         string `xml:"cryptokey"`
 }
 
+// money is a decimal.Decimal that always marshals as a schema-valid
+// two-decimal-place string, regardless of how many decimal places the
+// underlying value happens to carry (decimal.Decimal.String() drops
+// trailing zeros, so 10.50 would print as "10.5" and 10.00 as "10").
+type money decimal.Decimal
+
+func (m money) MarshalText() ([]byte, error) {
+	return []byte(decimal.Decimal(m).StringFixed(2)), nil
+}
+
+func (m *money) UnmarshalText(text []byte) error {
+	var d decimal.Decimal
+	if err := d.UnmarshalText(text); err != nil {
+		return err
+	}
+	*m = money(d)
+	return nil
+}
+
+// element returns the tag name and payload for whichever field of l is set,
+// matching the struct field tags above.
+func (l LogType) element() (string, interface{}) {
+	switch {
+	case l.UserCommand != nil:
+		return "userCommand", l.UserCommand
+	case l.AccountTransaction != nil:
+		return "accountTransaction", l.AccountTransaction
+	case l.SystemEvent != nil:
+		return "systemEvent", l.SystemEvent
+	case l.QuoteServer != nil:
+		return "quoteServer", l.QuoteServer
+	case l.ErrorEvent != nil:
+		return "errorEventm", l.ErrorEvent
+	default:
+		return "", nil
+	}
+}
+
 type ErrorEventType struct {
 	Timestamp         string `xml:"timestamp"`
 	Server            string `xml:"server"`
@@ -114,7 +156,7 @@ type ErrorEventType struct {
 	Command           string `xml:"action"`
 	Username          string `xml:"username"`
 	StockSymbol       string `xml:"stockSymbol"`
-	Funds             string `xml:"funds"`
+	Funds             money  `xml:"funds"`
 	ErrorMessage      string `xml:"error"`
 }
 
@@ -124,63 +166,61 @@ const schemaFile = "logger/schema.xsd"
 const prefix = ""
 const indent = "\t"
 
-func formatBalance(balance string) string {
-	b, err := strconv.Atoi(balance)
+// audit is the AuditLogger started by InitLogger. LogCommand and
+// LogQuoteServ enqueue onto it rather than touching disk themselves.
+var audit *AuditLogger
+
+// fileBacked records whether InitLogger was given a FileSink writing to
+// logfile, the only Sink DumpLog/DumpLogAll/AccountTransactionsForUser can
+// read back from: they all open logfile directly rather than going through
+// sink. With StdoutSink or HTTPSink, the audit stream never lands there.
+var fileBacked bool
+
+// InitLogger starts the package's AuditLogger writing to sink and returns it
+// so callers can Shutdown it on exit. It must be called before LogCommand or
+// LogQuoteServ.
+//
+// DumpLog, DumpLogAll, and AccountTransactionsForUser only work when sink is
+// a *FileSink writing to logfile ("log.xsd"): they read that file back
+// directly instead of going through sink. Pass a *FileSink if DUMPLOG or OFX
+// export need to work; with any other Sink they return an error instead of
+// silently producing an empty or stale result.
+func InitLogger(sink Sink, debug bool) (*AuditLogger, error) {
+	l, err := newAuditLogger(sink, debug)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return fmt.Sprintf("%d.%d", b/100, b%100)
+	audit = l
+	fs, ok := sink.(*FileSink)
+	fileBacked = ok && filepath.Clean(fs.file.Name()) == logfile
+	return l, nil
 }
 
-func getUnixTimestamp() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano()/int64(time.Millisecond))
+// Flush blocks until every entry Enqueued so far has been written to the
+// sink, or ctx expires. Anything that reads the audit log back right after
+// logging to it (DUMPLOG, OFX export) needs this to avoid racing the
+// background writer.
+func Flush(ctx context.Context) error {
+	return audit.Flush(ctx)
 }
 
-func validateSchema(ele []byte) {
-	schema, err := os.Open(schemaFile)
-	if err != nil {
-		fmt.Printf("failed to open file: %s", err)
-		return
-	}
-	defer schema.Close()
-
-	schemabuf, err := ioutil.ReadAll(schema)
-	if err != nil {
-		fmt.Printf("failed to read file: %s", err)
-		return
-	}
-
-	s, err := xsd.Parse(schemabuf)
-	if err != nil {
-		fmt.Printf("failed to parse XSD: %s", err)
-		return
-	}
-	defer s.Free()
-
-	d, err := libxml2.Parse(ele)
+// parseCentsAmount turns an integer-cent amount (e.g. "1005") into a
+// schema-valid two-decimal Decimal (e.g. 10.05). It returns an error
+// instead of panicking so a malformed amount can't take down the caller.
+func parseCentsAmount(balance string) (decimal.Decimal, error) {
+	cents, err := strconv.ParseInt(balance, 10, 64)
 	if err != nil {
-		fmt.Printf("failed to parse XML: %s", err)
-		return
-	}
-
-	if err := s.Validate(d); err != nil {
-		for _, e := range err.(xsd.SchemaValidationError).Errors() {
-			fmt.Printf("error: %s", e.Error())
-		}
-		return
+		return decimal.Decimal{}, fmt.Errorf("invalid amount %q: %w", balance, err)
 	}
+	return models.MoneyFromCents(cents), nil
+}
 
-	fmt.Printf("xml validation successful!")
+func getUnixTimestamp() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano()/int64(time.Millisecond))
 }
 
 func LogCommand(command Command, vars map[string]string) {
 	if _, exist := validCommands[command]; exist {
-		file, err := os.OpenFile(logfile, os.O_APPEND|os.O_WRONLY, 0600)
-		if err != nil {
-			panic(err)
-		}
-		defer file.Close()
-
 		timestamp := getUnixTimestamp()
 		v := UserCommandType{Timestamp: timestamp, Server: server, Command: command}
 
@@ -197,26 +237,73 @@ func LogCommand(command Command, vars map[string]string) {
 			v.Filename = val
 		}
 		if val, exist := vars["amount"]; exist {
-			v.Funds = formatBalance(val)
+			funds, err := parseCentsAmount(val)
+			if err != nil {
+				fmt.Printf("failed to format amount %q: %s", val, err)
+			} else {
+				m := money(funds)
+				v.Funds = &m
+			}
 		}
 
-		logEntry := LogType{UserCommand: &v}
+		audit.Enqueue(LogType{UserCommand: &v})
+
+		if v.Funds != nil && isAccountTransaction(command) {
+			audit.Enqueue(LogType{AccountTransaction: &AccountTransactionType{
+				Timestamp:         timestamp,
+				Server:            server,
+				TransactionNumber: v.TransactionNumber,
+				Command:           string(command),
+				Username:          v.Username,
+				Funds:             *v.Funds,
+			}})
+		}
 
-		output, err := xml.MarshalIndent(logEntry, prefix, indent)
-		if err != nil {
-			panic(err)
+		if command == DUMPLOG {
+			dump(v.Username, v.Filename)
 		}
-		file.Write(output)
-		validateSchema(output)
 	}
 }
 
-func LogQuoteServ(username string, price string, stocksymbol string, quoteTimestamp string, cryptokey string, trans string) {
-	file, err := os.OpenFile(logfile, os.O_APPEND|os.O_WRONLY, 0600)
+// isAccountTransaction reports whether command moves real cash in or out of
+// the account, as opposed to merely reserving it (BUY/SELL) or releasing a
+// reservation (CANCEL_BUY/CANCEL_SELL). Only these are worth an
+// AccountTransaction entry for statement export.
+func isAccountTransaction(command Command) bool {
+	switch command {
+	case ADD, COMMIT_BUY, COMMIT_SELL:
+		return true
+	default:
+		return false
+	}
+}
+
+func dump(username, filename string) {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := audit.Flush(ctx); err != nil {
+		fmt.Printf("failed to flush audit log before dump: %s", err)
+	}
+
+	var err error
+	if username != "" {
+		err = DumpLog(username, filename)
+	} else {
+		err = DumpLogAll(filename)
+	}
+	if err != nil {
+		fmt.Printf("failed to dump log: %s", err)
+	}
+}
+
+// LogQuoteServ records a quote server response. It returns an error if
+// price isn't a valid decimal rather than logging a malformed entry.
+func LogQuoteServ(username string, price string, stocksymbol string, quoteTimestamp string, cryptokey string, trans string) error {
+	value, err := decimal.NewFromString(price)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid quote price %q: %w", price, err)
 	}
-	defer file.Close()
 
 	timestamp := getUnixTimestamp()
 
@@ -225,19 +312,12 @@ func LogQuoteServ(username string, price string, stocksymbol string, quoteTimest
 		QuoteServerTime:   quoteTimestamp,
 		Username:          username,
 		StockSymbol:       stocksymbol,
-		Price:             price,
+		Price:             money(value),
 		CryptoKey:         cryptokey,
 		TransactionNumber: trans}
 
-	logEntry := LogType{QuoteServer: &v}
-
-	output, err := xml.MarshalIndent(logEntry, prefix, indent)
-	if err != nil {
-		panic(err)
-	}
-
-	file.Write(output)
-	validateSchema(output)
+	audit.Enqueue(LogType{QuoteServer: &v})
+	return nil
 }
 
 // func LogTransaction(command string, username string, funds string) {
@@ -261,50 +341,33 @@ func LogQuoteServ(username string, price string, stocksymbol string, quoteTimest
 
 // }
 
-// func LogSystemEvnt(command string, username string, stocksymbol string, funds string) {
-
-// 	file, err := os.OpenFile("log.xsd", os.O_APPEND|os.O_WRONLY, 0600)
-// 	if err != nil {
-// 		panic(err)
-// 	}
-
-// 	v := &SystemEvent{Timestamp: strconv.FormatInt(time.Now().UTC().UnixNano(), 10), Server: 1, Command: command, Username: username, StockSymbol: stocksymbol, Funds: funds}
-
-// 	output, err := xml.MarshalIndent(v, "  ", "    ")
-
-// 	if err != nil {
-
-// 		fmt.Printf("error: %v\n", err)
-
-// 	}
-
-// 	file.Write(output)
-
-// }
-
-// func LogErrorEvent(command string, username string, stocksymbol string, funds string, emessage string) {
-
-// 	file, err := os.OpenFile("log.xsd", os.O_APPEND|os.O_WRONLY, 0600)
-// 	if err != nil {
-// 		panic(err)
-// 	}
-
-// 	v := ErrorEvent{Timestamp: strconv.FormatInt(time.Now().UTC().UnixNano(), 10), Server: 1, Command: command, Username: username, StockSymbol: stocksymbol, Funds: funds, ErrorMessage: emessage}
-
-// 	output, err := xml.MarshalIndent(v, "  ", "    ")
-
-// 	if err != nil {
-// 		panic(err)
-// 	}
-
-// 	file.Write(output)
-// }
+// LogSystemEvent records a system-initiated action, such as a trigger firing
+// a BUY or SELL, that wasn't driven directly by a user command.
+func LogSystemEvent(command Command, username, symbol string, funds decimal.Decimal) {
+	v := SystemEventType{
+		Timestamp:   getUnixTimestamp(),
+		Server:      server,
+		Command:     string(command),
+		Username:    username,
+		StockSymbol: symbol,
+		Funds:       money(funds),
+	}
 
-func InitLogger() {
-	_, err := os.Create(logfile)
+	audit.Enqueue(LogType{SystemEvent: &v})
+}
 
-	if err != nil {
-		return
+// LogErrorEvent records a failure encountered while processing command on
+// behalf of username, e.g. a trigger that couldn't be fired.
+func LogErrorEvent(command Command, username, symbol string, funds decimal.Decimal, errMessage string) {
+	v := ErrorEventType{
+		Timestamp:    getUnixTimestamp(),
+		Server:       server,
+		Command:      string(command),
+		Username:     username,
+		StockSymbol:  symbol,
+		Funds:        money(funds),
+		ErrorMessage: errMessage,
 	}
 
+	audit.Enqueue(LogType{ErrorEvent: &v})
 }
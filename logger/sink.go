@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Sink is a destination for marshaled audit log entries. The AuditLogger
+// only ever calls Write from its single background goroutine, and Close only
+// after that goroutine has exited, so implementations don't need to be safe
+// for concurrent use.
+type Sink interface {
+	Write(p []byte) error
+	Close() error
+}
+
+// FileSink appends entries to a file on disk, creating it if necessary. It's
+// the only Sink that DumpLog/DumpLogAll/AccountTransactionsForUser can read
+// back from, since those read logfile directly rather than going through
+// Sink.
+type FileSink struct {
+	file *os.File
+}
+
+// NewFileSink opens path for append-only writes.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Write(p []byte) error {
+	_, err := s.file.Write(p)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// StdoutSink writes entries to standard output, useful for local
+// development where tailing log.xsd isn't convenient.
+type StdoutSink struct{}
+
+func (StdoutSink) Write(p []byte) error {
+	_, err := os.Stdout.Write(p)
+	return err
+}
+
+func (StdoutSink) Close() error {
+	return nil
+}
+
+// HTTPSink POSTs each entry to a collector endpoint so the audit log can be
+// shipped off-box instead of staying on local disk. Entries sent through it
+// never reach logfile, so DumpLog/DumpLogAll return an error with this sink.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns a Sink that POSTs entries to url.
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{url: url, client: &http.Client{}}
+}
+
+func (s *HTTPSink) Write(p []byte) error {
+	resp, err := s.client.Post(s.url, "application/xml", bytes.NewReader(p))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("audit log sink: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	return nil
+}
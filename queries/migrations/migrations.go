@@ -0,0 +1,87 @@
+// Package migrations embeds the versioned SQL files that define the
+// users/stocks/reservations/triggers schema, in the rockhopper style: one
+// timestamped *.sql file per change, split into a "-- +up" and a
+// "-- +down" section.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+const (
+	upMarker   = "-- +up"
+	downMarker = "-- +down"
+)
+
+// Migration is one versioned schema change, named after its file
+// (<version>_<name>.sql).
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load parses every embedded *.sql file into a Migration, ordered by
+// version (the leading timestamp in the filename).
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		m, err := parse(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("parsing migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func parse(filename string) (Migration, error) {
+	stem := strings.TrimSuffix(filename, ".sql")
+	sep := strings.Index(stem, "_")
+	if sep < 0 {
+		return Migration{}, fmt.Errorf("filename %q must be <version>_<name>.sql", filename)
+	}
+	version, name := stem[:sep], stem[sep+1:]
+
+	contents, err := files.ReadFile(filename)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	downIdx := strings.Index(string(contents), downMarker)
+	if downIdx < 0 {
+		return Migration{}, fmt.Errorf("missing %q section", downMarker)
+	}
+
+	up := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(contents)[:downIdx]), upMarker))
+	down := strings.TrimSpace(string(contents)[downIdx+len(downMarker):])
+
+	return Migration{
+		Version: version,
+		Name:    name,
+		Up:      up,
+		Down:    down,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type OrderType string
+
+const (
+	BUY  = OrderType("buy")
+	SELL = OrderType("sell")
+)
+
+type User struct {
+	ID       int64
+	Username string
+	Money    decimal.Decimal
+}
+
+type Stock struct {
+	ID       int64
+	Username string
+	Symbol   string
+	Shares   int64
+}
+
+type Trigger struct {
+	ID           int64
+	Username     string
+	Symbol       string
+	Order        OrderType
+	Amount       decimal.Decimal
+	Shares       int64
+	TriggerPrice decimal.Decimal
+	Executable   bool
+	Time         time.Time
+}
+
+type Reservation struct {
+	ID       int64
+	Username string
+	Symbol   string
+	Shares   int64
+	Amount   decimal.Decimal
+	Order    OrderType
+	Time     time.Time
+}
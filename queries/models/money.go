@@ -0,0 +1,14 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// MoneyFromCents converts an integer number of cents (e.g. 1005) into a
+// Decimal dollar amount (e.g. 10.05).
+func MoneyFromCents(cents int64) decimal.Decimal {
+	return decimal.New(cents, -2)
+}
+
+// MoneyFromString parses a decimal dollar amount such as "10.05".
+func MoneyFromString(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(s)
+}
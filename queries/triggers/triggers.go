@@ -0,0 +1,285 @@
+// Package triggers polls the triggers table and fires BUY/SELL orders once
+// the quote server reports a price crossing their threshold.
+package triggers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
+
+	"transaction_service/logger"
+	"transaction_service/queries/models"
+	dbutils "transaction_service/queries/utils"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultWorkers      = 8
+)
+
+// TriggerEngine polls for executable triggers and fires them once the quote
+// server reports a price crossing their threshold. The zero value is not
+// usable; construct one with NewTriggerEngine.
+type TriggerEngine struct {
+	db           *sql.DB
+	pollInterval time.Duration
+	workers      int
+
+	group singleflight.Group
+	fired chan models.Trigger
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTriggerEngine constructs a TriggerEngine against db. Call Start to
+// begin polling.
+func NewTriggerEngine(db *sql.DB) *TriggerEngine {
+	return &TriggerEngine{
+		db:           db,
+		pollInterval: defaultPollInterval,
+		workers:      defaultWorkers,
+		fired:        make(chan models.Trigger, defaultWorkers),
+		done:         make(chan struct{}),
+	}
+}
+
+// TriggerFired emits a trigger every time it is successfully dispatched, so
+// HTTP handlers can subscribe to fills without polling the database. The send
+// never blocks: when the channel's buffer is full the notification is
+// dropped and an ErrorEvent is logged instead.
+func (e *TriggerEngine) TriggerFired() <-chan models.Trigger {
+	return e.fired
+}
+
+// Start launches the background polling goroutine. Stop the engine (or
+// cancel ctx) to shut it down.
+func (e *TriggerEngine) Start(ctx context.Context) {
+	e.wg.Add(1)
+	go e.run(ctx)
+}
+
+// Stop signals the polling goroutine to exit and waits for it to finish.
+func (e *TriggerEngine) Stop() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+func (e *TriggerEngine) run(ctx context.Context) {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.poll(ctx)
+		case <-e.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll selects candidate triggers, fetches a quote per distinct symbol, and
+// dispatches every trigger whose threshold the quote has crossed.
+func (e *TriggerEngine) poll(ctx context.Context) {
+	pending, err := e.selectExecutable(ctx)
+	if err != nil {
+		logger.LogErrorEvent(logger.DISPLAY_SUMMARY, "", "", decimal.Decimal{}, fmt.Sprintf("selecting triggers: %s", err))
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	quotes := e.fetchQuotes(ctx, pending)
+
+	for _, t := range pending {
+		quote, ok := quotes[t.Symbol]
+		if !ok || !crossed(t.Order, quote, t.TriggerPrice) {
+			continue
+		}
+
+		claimed, err := e.claim(ctx, t.ID)
+		if err != nil {
+			logger.LogErrorEvent(systemCommand(t.Order), t.Username, t.Symbol, decimal.Decimal{}, fmt.Sprintf("claiming trigger %d: %s", t.ID, err))
+			continue
+		}
+		if !claimed {
+			// Another worker already fired this trigger.
+			continue
+		}
+
+		e.dispatch(t, quote)
+	}
+}
+
+// selectExecutable fetches every trigger still armed, relying on the
+// partial index on triggers(executable) to keep this cheap as the table
+// grows.
+func (e *TriggerEngine) selectExecutable(ctx context.Context) ([]models.Trigger, error) {
+	const query = `SELECT tid, username, symbol, type, amount, shares, trigger_price, executable, time
+		FROM triggers WHERE executable = true`
+
+	rows, err := e.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []models.Trigger
+	for rows.Next() {
+		var t models.Trigger
+		var amount, triggerPrice decimal.NullDecimal
+		if err := rows.Scan(&t.ID, &t.Username, &t.Symbol, &t.Order, &amount, &t.Shares, &triggerPrice, &t.Executable, &t.Time); err != nil {
+			return nil, err
+		}
+		t.Amount = amount.Decimal
+		t.TriggerPrice = triggerPrice.Decimal
+		pending = append(pending, t)
+	}
+
+	return pending, rows.Err()
+}
+
+// fetchQuotes fetches one quote per distinct symbol among triggers,
+// deduplicating concurrent requests for the same symbol with a
+// singleflight.Group and bounding concurrency to e.workers so the quote
+// server isn't hammered by a large backlog.
+func (e *TriggerEngine) fetchQuotes(ctx context.Context, triggers []models.Trigger) map[string]decimal.Decimal {
+	usernames := make(map[string]string)
+	for _, t := range triggers {
+		if _, exists := usernames[t.Symbol]; !exists {
+			usernames[t.Symbol] = t.Username
+		}
+	}
+
+	quotes := make(map[string]decimal.Decimal, len(usernames))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, e.workers)
+	for symbol, username := range usernames {
+		symbol, username := symbol, username
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			v, err, _ := e.group.Do(symbol, func() (interface{}, error) {
+				return e.queryQuote(username, symbol)
+			})
+			if err != nil {
+				logger.LogErrorEvent(logger.QUOTE, username, symbol, decimal.Decimal{}, err.Error())
+				return
+			}
+
+			mu.Lock()
+			quotes[symbol] = v.(decimal.Decimal)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return quotes
+}
+
+func (e *TriggerEngine) queryQuote(username, symbol string) (decimal.Decimal, error) {
+	body, err := dbutils.QueryQuote(username, symbol)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	fields := strings.Split(string(body), ",")
+	if len(fields) == 0 {
+		return decimal.Decimal{}, fmt.Errorf("unexpected quote response: %q", body)
+	}
+
+	return decimal.NewFromString(strings.TrimSpace(fields[0]))
+}
+
+// crossed reports whether quote has reached order's threshold: a BUY fires
+// once the price has fallen to or below the trigger price, a SELL once it
+// has risen to or above it.
+func crossed(order models.OrderType, quote, triggerPrice decimal.Decimal) bool {
+	if order == models.BUY {
+		return quote.LessThanOrEqual(triggerPrice)
+	}
+	return quote.GreaterThanOrEqual(triggerPrice)
+}
+
+// claim atomically flips a trigger to non-executable inside a
+// SELECT ... FOR UPDATE transaction so that concurrent pollers never fire
+// the same trigger twice. It reports false (with a nil error) if the
+// trigger was already claimed by the time the row lock was acquired.
+func (e *TriggerEngine) claim(ctx context.Context, tid int64) (bool, error) {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var executable bool
+	err = tx.QueryRowContext(ctx, "SELECT executable FROM triggers WHERE tid = $1 FOR UPDATE", tid).Scan(&executable)
+	if err != nil {
+		return false, err
+	}
+	if !executable {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE triggers SET executable = false WHERE tid = $1", tid); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (e *TriggerEngine) dispatch(t models.Trigger, quote decimal.Decimal) {
+	logger.LogSystemEvent(systemCommand(t.Order), t.Username, t.Symbol, t.Amount)
+
+	select {
+	case e.fired <- t:
+	default:
+		logger.LogErrorEvent(systemCommand(t.Order), t.Username, t.Symbol, decimal.Decimal{}, "TriggerFired channel full, dropping notification")
+	}
+}
+
+func systemCommand(order models.OrderType) logger.Command {
+	if order == models.BUY {
+		return logger.BUY
+	}
+	return logger.SELL
+}
+
+// AddTrigger inserts a new armed trigger and returns its id.
+func (e *TriggerEngine) AddTrigger(ctx context.Context, t models.Trigger) (int64, error) {
+	const query = `INSERT INTO triggers (username, symbol, type, amount, shares, trigger_price, executable, time)
+		VALUES ($1, $2, $3, $4, $5, $6, true, now()) RETURNING tid`
+
+	var tid int64
+	err := e.db.QueryRowContext(ctx, query, t.Username, t.Symbol, t.Order, t.Amount, t.Shares, t.TriggerPrice).Scan(&tid)
+	return tid, err
+}
+
+// CancelTrigger disarms a trigger so the poller skips it on the next tick.
+func (e *TriggerEngine) CancelTrigger(ctx context.Context, tid int64) error {
+	const query = `UPDATE triggers SET executable = false WHERE tid = $1`
+	_, err := e.db.ExecContext(ctx, query, tid)
+	return err
+}
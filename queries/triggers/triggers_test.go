@@ -0,0 +1,88 @@
+package triggers
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"transaction_service/logger"
+	dbutils "transaction_service/queries/utils"
+)
+
+// TestClaimFiresOnce exercises the SELECT ... FOR UPDATE claim that's the
+// whole point of TriggerEngine: when two pollers race to claim the same
+// trigger, exactly one must win. It needs a real Postgres database (sqlite
+// doesn't support FOR UPDATE), so it's skipped unless TRANSSERVICE_TEST_DATABASE_URL
+// is set, e.g.:
+//
+//	TRANSSERVICE_TEST_DATABASE_URL=postgres://localhost/transservice_test?sslmode=disable go test ./queries/triggers/...
+func TestClaimFiresOnce(t *testing.T) {
+	dsn := os.Getenv("TRANSSERVICE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TRANSSERVICE_TEST_DATABASE_URL not set; skipping test that needs a real Postgres database")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	dbutils.SetUtilsDB(db)
+	ctx := context.Background()
+	if err := dbutils.MigrateDB(ctx); err != nil {
+		t.Fatalf("MigrateDB: %s", err)
+	}
+
+	if _, err := logger.InitLogger(logger.StdoutSink{}, false); err != nil {
+		t.Fatalf("InitLogger: %s", err)
+	}
+
+	username := "trigger_test_user"
+	if _, err := db.ExecContext(ctx, `INSERT INTO users (username, money) VALUES ($1, 0)
+		ON CONFLICT (username) DO NOTHING`, username); err != nil {
+		t.Fatalf("inserting test user: %s", err)
+	}
+
+	var tid int64
+	err = db.QueryRowContext(ctx, `INSERT INTO triggers (username, symbol, type, amount, shares, trigger_price, executable)
+		VALUES ($1, 'TEST', 'BUY', 10, 1, 10, true) RETURNING tid`, username).Scan(&tid)
+	if err != nil {
+		t.Fatalf("inserting test trigger: %s", err)
+	}
+	defer db.ExecContext(ctx, "DELETE FROM triggers WHERE tid = $1", tid)
+
+	engine := NewTriggerEngine(db)
+
+	const racers = 8
+	results := make([]bool, racers)
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := engine.claim(ctx, tid)
+			if err != nil {
+				t.Errorf("claim: %s", err)
+				return
+			}
+			results[i] = claimed
+		}()
+	}
+	wg.Wait()
+
+	wins := 0
+	for _, claimed := range results {
+		if claimed {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Fatalf("got %d winning claims among %d racers, want exactly 1", wins, racers)
+	}
+}
@@ -0,0 +1,89 @@
+package dbutils
+
+import (
+	"context"
+	"fmt"
+
+	"transaction_service/queries/migrations"
+)
+
+const createSchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    TEXT PRIMARY KEY,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// MigrateDB applies every migration in queries/migrations that isn't yet
+// recorded in schema_migrations, in version order, each inside its own
+// transaction. Call it once at startup, after SetUtilsDB.
+func MigrateDB(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, createSchemaMigrationsTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	pending, err := migrations.Load()
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+
+	for _, m := range pending {
+		applied, err := migrationApplied(ctx, m.Version)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		if err := applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("applying migration %s_%s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func migrationApplied(ctx context.Context, version string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists)
+	return exists, err
+}
+
+func applyMigration(ctx context.Context, m migrations.Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", m.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reset tears down and re-creates the whole schema by running every
+// migration's down script in reverse order and then re-running MigrateDB.
+// It's meant for test suites that want a clean database between runs, not
+// for production use.
+func Reset(ctx context.Context) error {
+	all, err := migrations.Load()
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		if _, err := db.ExecContext(ctx, all[i].Down); err != nil {
+			return fmt.Errorf("reverting migration %s_%s: %w", all[i].Version, all[i].Name, err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS schema_migrations"); err != nil {
+		return err
+	}
+
+	return MigrateDB(ctx)
+}
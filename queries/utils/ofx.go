@@ -0,0 +1,224 @@
+package dbutils
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"transaction_service/logger"
+	"transaction_service/queries/models"
+)
+
+const ofxDateLayout = "20060102150405"
+
+// flushTimeout bounds how long ExportOFX waits for the audit log's
+// background writer to catch up before reading account transactions back.
+const flushTimeout = 5 * time.Second
+
+// ExportOFX writes an OFX 2.x statement of username's cash and investment
+// activity between from and to to w: executed fund transfers as
+// <BANKMSGSRSV1>/<STMTTRN> entries, and share buys/sells as
+// <INVSTMTMSGSRSV1>/<BUYSTOCK>/<SELLSTOCK> entries.
+func ExportOFX(username string, from, to time.Time, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := logger.Flush(ctx); err != nil {
+		return fmt.Errorf("flushing audit log: %w", err)
+	}
+
+	transactions, err := logger.AccountTransactionsForUser(username, from, to)
+	if err != nil {
+		return fmt.Errorf("querying account transactions: %w", err)
+	}
+
+	reservations, err := reservationsForUser(username, from, to)
+	if err != nil {
+		return fmt.Errorf("querying reservations: %w", err)
+	}
+
+	holdings, err := holdingsForUser(username)
+	if err != nil {
+		return fmt.Errorf("querying holdings: %w", err)
+	}
+
+	doc := buildOFXDocument(from, to, transactions, reservations, holdings)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+func reservationsForUser(username string, from, to time.Time) ([]models.Reservation, error) {
+	query := `SELECT rid, username, symbol, shares, amount, type, time
+		FROM reservations WHERE username = $1 AND time BETWEEN $2 AND $3 ORDER BY time`
+
+	rows, err := db.Query(query, username, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Reservation
+	for rows.Next() {
+		var res models.Reservation
+		if err := rows.Scan(&res.ID, &res.Username, &res.Symbol, &res.Shares, &res.Amount, &res.Order, &res.Time); err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+
+	return out, rows.Err()
+}
+
+func holdingsForUser(username string) ([]models.Stock, error) {
+	query := "SELECT sid, username, symbol, shares FROM stocks WHERE username = $1"
+
+	rows, err := db.Query(query, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Stock
+	for rows.Next() {
+		var stock models.Stock
+		if err := rows.Scan(&stock.ID, &stock.Username, &stock.Symbol, &stock.Shares); err != nil {
+			return nil, err
+		}
+		out = append(out, stock)
+	}
+
+	return out, rows.Err()
+}
+
+func buildOFXDocument(from, to time.Time, transactions []logger.AccountTransactionType, reservations []models.Reservation, holdings []models.Stock) ofxDocument {
+	bankTrns := make([]ofxStmtTrn, 0, len(transactions))
+	for _, t := range transactions {
+		trnType := bankTrnType(t.Command)
+		amount := decimal.Decimal(t.Funds)
+		if trnType == "DEBIT" {
+			amount = amount.Neg()
+		}
+
+		bankTrns = append(bankTrns, ofxStmtTrn{
+			TrnType:  trnType,
+			DtPosted: ofxTimestamp(t.Timestamp),
+			TrnAmt:   amount.StringFixed(2),
+			FitID:    fitID(t.TransactionNumber, t.Timestamp),
+			Memo:     fmt.Sprintf("%s %s", t.Command, t.Username),
+		})
+	}
+
+	var buys []ofxBuyStock
+	var sells []ofxSellStock
+	for _, r := range reservations {
+		inv := ofxInvBuySell{
+			InvTran:   ofxInvTran{FitID: fitID(strconv.FormatInt(r.ID, 10), r.Time.Format(ofxDateLayout)), DtTrade: r.Time.Format(ofxDateLayout)},
+			SecID:     ofxSecID{UniqueID: r.Symbol, UniqueIDType: "TICKER"},
+			Units:     strconv.FormatInt(r.Shares, 10),
+			UnitPrice: unitPrice(r.Amount, r.Shares).StringFixed(2),
+			Total:     r.Amount.StringFixed(2),
+			Currency:  ofxCurrency{CurSym: "USD"},
+		}
+
+		if r.Order == models.BUY {
+			buys = append(buys, ofxBuyStock{InvBuy: inv, BuyType: "BUY"})
+		} else {
+			sells = append(sells, ofxSellStock{InvSell: inv, SellType: "SELL"})
+		}
+	}
+
+	positions := make([]ofxPosStock, 0, len(holdings))
+	for _, h := range holdings {
+		positions = append(positions, ofxPosStock{
+			SecID:    ofxSecID{UniqueID: h.Symbol, UniqueIDType: "TICKER"},
+			Units:    strconv.FormatInt(h.Shares, 10),
+			Currency: ofxCurrency{CurSym: "USD"},
+		})
+	}
+
+	return ofxDocument{
+		SignOn: ofxSignOnMsgSet{
+			SonRs: ofxSonRs{
+				Status:   ofxStatus{Code: 0, Severity: "INFO"},
+				DtServer: time.Now().Format(ofxDateLayout),
+				Language: "ENG",
+			},
+		},
+		Bank: ofxBankMsgSet{
+			StmtTrnRs: ofxStmtTrnRs{
+				Status: ofxStatus{Code: 0, Severity: "INFO"},
+				StmtRs: ofxStmtRs{
+					CurDef: "USD",
+					BankTranList: ofxBankTranList{
+						DtStart: from.Format(ofxDateLayout),
+						DtEnd:   to.Format(ofxDateLayout),
+						StmtTrn: bankTrns,
+					},
+				},
+			},
+		},
+		Inv: ofxInvMsgSet{
+			InvStmtTrnRs: ofxInvStmtTrnRs{
+				Status: ofxStatus{Code: 0, Severity: "INFO"},
+				InvStmtRs: ofxInvStmtRs{
+					DtAsOf:   time.Now().Format(ofxDateLayout),
+					Currency: ofxCurrency{CurSym: "USD"},
+					InvTranList: ofxInvTranList{
+						DtStart:   from.Format(ofxDateLayout),
+						DtEnd:     to.Format(ofxDateLayout),
+						BuyStock:  buys,
+						SellStock: sells,
+					},
+					InvPosList: ofxInvPosList{PosStock: positions},
+				},
+			},
+		},
+	}
+}
+
+// bankTrnType classifies a logged account transaction as OFX's DEBIT or
+// CREDIT: COMMIT_BUY takes cash out of the account, ADD and COMMIT_SELL put
+// cash in.
+func bankTrnType(command string) string {
+	if command == string(logger.COMMIT_BUY) {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+func ofxTimestamp(millis string) string {
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format(ofxDateLayout)
+}
+
+// unitPrice divides amount (the reservation's total) by shares to recover a
+// per-share price, or zero if there are no shares to divide by.
+func unitPrice(amount decimal.Decimal, shares int64) decimal.Decimal {
+	if shares == 0 {
+		return decimal.Zero
+	}
+	return amount.Div(decimal.NewFromInt(shares))
+}
+
+// fitID derives a stable OFX FITID from a transaction number and timestamp
+// so re-exporting the same range produces identical ids and importers treat
+// re-runs as idempotent.
+func fitID(transactionNum, timestamp string) string {
+	sum := sha1.Sum([]byte(transactionNum + "|" + timestamp))
+	return hex.EncodeToString(sum[:])
+}
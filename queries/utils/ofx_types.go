@@ -0,0 +1,126 @@
+package dbutils
+
+import "encoding/xml"
+
+// The types below mirror just enough of the OFX 2.x schema to express a
+// cash and investment statement: sign-on, a bank message set carrying
+// <STMTTRN> cash movements, and an investment message set carrying
+// <BUYSTOCK>/<SELLSTOCK> share transactions plus current holdings.
+
+type ofxDocument struct {
+	XMLName xml.Name        `xml:"OFX"`
+	SignOn  ofxSignOnMsgSet `xml:"SIGNONMSGSRSV1"`
+	Bank    ofxBankMsgSet   `xml:"BANKMSGSRSV1"`
+	Inv     ofxInvMsgSet    `xml:"INVSTMTMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxCurrency struct {
+	CurSym string `xml:"CURSYM"`
+}
+
+type ofxSecID struct {
+	UniqueID     string `xml:"UNIQUEID"`
+	UniqueIDType string `xml:"UNIQUEIDTYPE"`
+}
+
+type ofxSignOnMsgSet struct {
+	SonRs ofxSonRs `xml:"SONRS"`
+}
+
+type ofxSonRs struct {
+	Status   ofxStatus `xml:"STATUS"`
+	DtServer string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxBankMsgSet struct {
+	StmtTrnRs ofxStmtTrnRs `xml:"STMTTRNRS"`
+}
+
+type ofxStmtTrnRs struct {
+	Status ofxStatus `xml:"STATUS"`
+	StmtRs ofxStmtRs `xml:"STMTRS"`
+}
+
+type ofxStmtRs struct {
+	CurDef       string          `xml:"CURDEF"`
+	BankTranList ofxBankTranList `xml:"BANKTRANLIST"`
+}
+
+type ofxBankTranList struct {
+	DtStart string       `xml:"DTSTART"`
+	DtEnd   string       `xml:"DTEND"`
+	StmtTrn []ofxStmtTrn `xml:"STMTTRN"`
+}
+
+type ofxStmtTrn struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DtPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FitID    string `xml:"FITID"`
+	Memo     string `xml:"MEMO"`
+}
+
+type ofxInvMsgSet struct {
+	InvStmtTrnRs ofxInvStmtTrnRs `xml:"INVSTMTTRNRS"`
+}
+
+type ofxInvStmtTrnRs struct {
+	Status    ofxStatus    `xml:"STATUS"`
+	InvStmtRs ofxInvStmtRs `xml:"INVSTMTRS"`
+}
+
+type ofxInvStmtRs struct {
+	DtAsOf      string         `xml:"DTASOF"`
+	Currency    ofxCurrency    `xml:"CURRENCY"`
+	InvTranList ofxInvTranList `xml:"INVTRANLIST"`
+	InvPosList  ofxInvPosList  `xml:"INVPOSLIST"`
+}
+
+type ofxInvTranList struct {
+	DtStart   string         `xml:"DTSTART"`
+	DtEnd     string         `xml:"DTEND"`
+	BuyStock  []ofxBuyStock  `xml:"BUYSTOCK,omitempty"`
+	SellStock []ofxSellStock `xml:"SELLSTOCK,omitempty"`
+}
+
+type ofxInvTran struct {
+	FitID   string `xml:"FITID"`
+	DtTrade string `xml:"DTTRADE"`
+}
+
+// ofxInvBuySell is the shared body of a BUYSTOCK/SELLSTOCK record; OFX
+// nests it under INVBUY or INVSELL depending on direction.
+type ofxInvBuySell struct {
+	InvTran   ofxInvTran  `xml:"INVTRAN"`
+	SecID     ofxSecID    `xml:"SECID"`
+	Units     string      `xml:"UNITS"`
+	UnitPrice string      `xml:"UNITPRICE"`
+	Total     string      `xml:"TOTAL"`
+	Currency  ofxCurrency `xml:"CURRENCY"`
+}
+
+type ofxBuyStock struct {
+	InvBuy  ofxInvBuySell `xml:"INVBUY"`
+	BuyType string        `xml:"BUYTYPE"`
+}
+
+type ofxSellStock struct {
+	InvSell  ofxInvBuySell `xml:"INVSELL"`
+	SellType string        `xml:"SELLTYPE"`
+}
+
+type ofxInvPosList struct {
+	PosStock []ofxPosStock `xml:"POSSTOCK,omitempty"`
+}
+
+type ofxPosStock struct {
+	SecID    ofxSecID    `xml:"SECID"`
+	Units    string      `xml:"UNITS"`
+	Currency ofxCurrency `xml:"CURRENCY"`
+}
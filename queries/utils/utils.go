@@ -10,6 +10,8 @@ import (
 	//"strconv"
 	//"strings"
 
+	"github.com/shopspring/decimal"
+
 	//"transaction_service/utils"
 	"transaction_service/queries/models"
 )
@@ -42,11 +44,13 @@ func QueryQuote(username string, stock string) (body []byte, err error) {
 	return
 }
 
-func QueryUserAvailableBalance(username string) ( balance int, err error) {
+func QueryUserAvailableBalance(username string) (balance decimal.Decimal, err error) {
 	query := `SELECT (SELECT money FROM USERS WHERE username = $1) -
 			 (SELECT COALESCE(SUM(amount), 0) FROM RESERVATIONS WHERE username = $1 and type = $2)
 			 as available_balance;`
-	err = db.QueryRow(query, username, models.BUY).Scan(&balance)
+	var nb decimal.NullDecimal
+	err = db.QueryRow(query, username, models.BUY).Scan(&nb)
+	balance = nb.Decimal
 	return
 }
 
@@ -59,7 +63,9 @@ func QueryUserAvailableShares(username string, symbol string) (shares int, err e
 
 func QueryUser(username string) (user models.User, err error) {
 	query := "SELECT uid, username, money FROM users WHERE username = $1"
-	err = db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Money)
+	var money decimal.NullDecimal
+	err = db.QueryRow(query, username).Scan(&user.ID, &user.Username, &money)
+	user.Money = money.Decimal
 	return
 }
 
@@ -71,73 +77,40 @@ func QueryUserStock(username string, symbol string) (stock models.Stock, err err
 
 func QueryStockTrigger(tid int64) (trig models.Trigger, err error) {
 	query := "SELECT tid, username, symbol, type, amount, shares, trigger_price, executable, time FROM triggers WHERE tid = $1"
-	err = db.QueryRow(query, tid).Scan(&trig.ID, &trig.Username, &trig.Symbol, 
-						&trig.Order, &trig.Amount, &trig.Shares, &trig.TriggerPrice, &trig.Executable, &trig.Time)
-	return 
+	var amount, triggerPrice decimal.NullDecimal
+	err = db.QueryRow(query, tid).Scan(&trig.ID, &trig.Username, &trig.Symbol,
+						&trig.Order, &amount, &trig.Shares, &triggerPrice, &trig.Executable, &trig.Time)
+	trig.Amount = amount.Decimal
+	trig.TriggerPrice = triggerPrice.Decimal
+	return
 }
 
 func QueryUserTrigger(username string, symbol string, orderType models.OrderType) (trig models.Trigger, err error) {
 	query := "SELECT tid, username, symbol, type, amount, shares, trigger_price, executable, time FROM triggers WHERE username = $1 AND symbol=$2 AND type=$3"
-	err = db.QueryRow(query, username, symbol, orderType).Scan(&trig.ID, &trig.Username, &trig.Symbol, 
-						&trig.Order, &trig.Amount, &trig.Shares, &trig.TriggerPrice, &trig.Executable, &trig.Time)
-	return 
+	var amount, triggerPrice decimal.NullDecimal
+	err = db.QueryRow(query, username, symbol, orderType).Scan(&trig.ID, &trig.Username, &trig.Symbol,
+						&trig.Order, &amount, &trig.Shares, &triggerPrice, &trig.Executable, &trig.Time)
+	trig.Amount = amount.Decimal
+	trig.TriggerPrice = triggerPrice.Decimal
+	return
 }
 
-// func QueryAndExecuteCurrentTriggers() {
-// 	query := `SELECT username, symbol, type, shares, amount, trigger_price 
-// 				FROM triggers 
-// 					WHERE trigger_price IS NOT NULL AND amount IS NOT NULL`
-
-// 	rows, err := db.Query(query)
-
-// 	if err != nil {
-// 		return
-// 	}
-
-// 	defer rows.Close()
-
-// 	for rows.Next() {
-// 		var username string
-// 		var symbol string
-// 		var orderType string
-// 		var shares sql.NullInt64
-// 		var amount sql.NullFloat64
-// 		var triggerValue sql.NullFloat64
-
-// 		err := rows.Scan(&username, &symbol, &orderType, &shares, &amount, &triggerValue)
-// 		if err != nil {
-// 			utils.LogErr(err)
-// 		}
-
-// 		isSell := strings.Compare(orderType, "sell") == 0
-// 		if (isSell && shares.Int64 > 0) || (!isSell && triggerValue.Float64 > 0) {
-// 			log.Println("Executing trigger (username,stock):")
-// 			log.Println(username)
-// 			log.Println(symbol)
-// 			quoteStr, err := QueryQuote(username, symbol)
-// 			if err == nil {
-// 				quote, _ := strconv.ParseFloat(strings.Split(string(quoteStr), ",")[0], 64)
-// 				if quote <= triggerValue.Float64 {
-// 					url := fmt.Sprintf("http://localhost:8888/api/executeTrigger/%s/%s/%d/%f/%f/%s", username, symbol, shares.Int64, amount.Float64, triggerValue.Float64, orderType)
-// 					go http.Get(url)
-// 				}
-// 			} else {
-// 				utils.LogErr(err)
-// 			}
-// 		}
-// 	}
-
-// 	return
-// }
+// The polling loop that fires executable triggers is implemented by
+// transaction_service/queries/triggers.TriggerEngine, which queries
+// executable triggers, fetches quotes concurrently, and fires them.
 
 func QueryReservation(rid int64) (res models.Reservation, err error) {
 	query := "SELECT rid, username, symbol, shares, amount, type, time FROM reservations WHERE rid=$1"
-	err = db.QueryRow(query, rid).Scan(&res.ID, &res.Username, &res.Symbol, &res.Shares, &res.Amount, &res.Order, &res.Time)
+	var amount decimal.NullDecimal
+	err = db.QueryRow(query, rid).Scan(&res.ID, &res.Username, &res.Symbol, &res.Shares, &amount, &res.Order, &res.Time)
+	res.Amount = amount.Decimal
 	return
 }
 
 func QueryLastReservation(username string, resType models.OrderType) (res models.Reservation, err error) {
 	query := "SELECT rid, username, symbol, shares, amount, type, time FROM reservations WHERE username=$1 and type=$2 ORDER BY (time) DESC, rid DESC LIMIT 1"
-	err = db.QueryRow(query, username, resType).Scan(&res.ID, &res.Username, &res.Symbol, &res.Shares, &res.Amount, &res.Order, &res.Time)
+	var amount decimal.NullDecimal
+	err = db.QueryRow(query, username, resType).Scan(&res.ID, &res.Username, &res.Symbol, &res.Shares, &amount, &res.Order, &res.Time)
+	res.Amount = amount.Decimal
 	return
 }